@@ -0,0 +1,64 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// captureRequest is a single unit of camera work. The camera hardware only
+// supports one capture at a time, so every raspistill/raspivid invocation is
+// funneled through captureQueue and run by a single worker goroutine.
+type captureRequest struct {
+	run    func() (string, error)
+	result chan captureResult
+}
+
+type captureResult struct {
+	path string
+	err  error
+}
+
+var captureQueue = make(chan captureRequest, 32)
+
+var captureStats struct {
+	sync.Mutex
+	lastDuration time.Duration
+}
+
+// startCaptureWorker launches the single goroutine that serializes all camera access
+func startCaptureWorker() {
+	go func() {
+		for req := range captureQueue {
+			started := time.Now()
+			path, err := req.run()
+			recordCaptureDuration(time.Since(started))
+			req.result <- captureResult{path: path, err: err}
+		}
+	}()
+}
+
+// enqueueCapture runs fn on the single capture worker and waits for its result
+func enqueueCapture(fn func() (string, error)) (string, error) {
+	req := captureRequest{run: fn, result: make(chan captureResult, 1)}
+	captureQueue <- req
+	res := <-req.result
+	return res.path, res.err
+}
+
+// captureQueueDepth returns the number of capture requests waiting to run
+func captureQueueDepth() int {
+	return len(captureQueue)
+}
+
+func recordCaptureDuration(d time.Duration) {
+	captureStats.Lock()
+	captureStats.lastDuration = d
+	captureStats.Unlock()
+}
+
+// lastCaptureDuration returns how long the most recently finished capture took
+func lastCaptureDuration() time.Duration {
+	captureStats.Lock()
+	defer captureStats.Unlock()
+	return captureStats.lastDuration
+}
@@ -0,0 +1,78 @@
+// package conf holds constant values and the config file structure shared
+// across the bot
+package conf
+
+const (
+	DefaultMonitorIntervalSeconds = 1
+
+	CommandStart     = "/start"
+	CommandCapture   = "/capture"
+	CommandVideo     = "/video"
+	CommandTimelapse = "/timelapse"
+	CommandStatus    = "/status"
+	CommandHelp      = "/help"
+	CommandCancel    = "/cancel"
+	CommandMotion    = "/motion"
+
+	MessageDefault        = "Welcome! This is a bot for a rpi camera module."
+	MessageUnknownCommand = "Unknown command"
+
+	DefaultVideoMaxSeconds = 30
+	DefaultVideoBitrate    = 1700000
+
+	DefaultCacheMaxEntries = 20
+	DefaultCacheTTLSeconds = 3600
+
+	InlineQueryResultCount = 10
+
+	DefaultMotionIntervalSeconds = 5
+	DefaultMotionSensitivity     = 15.0
+
+	DefaultMaxCapturesPerMinute = 10
+)
+
+// WebhookConfig is the set of options needed to run the bot in webhook mode
+// instead of long-polling. When URL is empty, the bot falls back to polling.
+//
+// SecretToken is required to enable webhook mode: it's registered with
+// Telegram via setWebhook and echoed back on every request as the
+// X-Telegram-Bot-Api-Secret-Token header, so the listener can reject
+// requests that don't carry it instead of trusting the claimed sender
+// identity in the update body alone.
+type WebhookConfig struct {
+	URL         string `json:"url"`
+	CertFile    string `json:"cert_file"`
+	KeyFile     string `json:"key_file"`
+	ListenAddr  string `json:"listen_addr"`
+	SecretToken string `json:"secret_token"`
+}
+
+// MotionConfig configures the background motion-triggered auto-capture watcher
+type MotionConfig struct {
+	IntervalSeconds int     `json:"interval_seconds"`
+	Sensitivity     float64 `json:"sensitivity"`
+}
+
+// Config is the content of the config file (config.json)
+type Config struct {
+	ApiToken        string        `json:"api_token"`
+	AvailableIds    []string      `json:"available_ids"`
+	MonitorInterval int           `json:"monitor_interval"`
+	ImageWidth      int           `json:"image_width"`
+	ImageHeight     int           `json:"image_height"`
+	IsVerbose       bool          `json:"is_verbose"`
+	Webhook         WebhookConfig `json:"webhook"`
+
+	VideoMaxSeconds int `json:"video_max_seconds"`
+	VideoBitrate    int `json:"video_bitrate"`
+	VideoWidth      int `json:"video_width"`
+	VideoHeight     int `json:"video_height"`
+
+	CacheDir        string `json:"cache_dir"`
+	CacheMaxEntries int    `json:"cache_max_entries"`
+	CacheTTLSeconds int    `json:"cache_ttl_seconds"`
+
+	Motion MotionConfig `json:"motion"`
+
+	MaxCapturesPerMinute int `json:"max_captures_per_minute"`
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+
+	tg "github.com/meinside/telegram-bot-go"
+)
+
+// Context carries a single update through the middleware chain and handlers,
+// along with the sender's session and shortcuts for replying.
+type Context struct {
+	Bot     *Bot
+	Update  tg.Update
+	Session *Session
+	UserId  string
+}
+
+// Text returns the update's message text, or an empty string if it has none
+func (c *Context) Text() string {
+	if c.Update.Message != nil && c.Update.Message.HasText() {
+		return *c.Update.Message.Text
+	}
+	return ""
+}
+
+// ChatId returns the chat id the update's message was sent in
+func (c *Context) ChatId() int64 {
+	return c.Update.Message.Chat.Id
+}
+
+// Typing sends a 'typing...' chat action
+func (c *Context) Typing() {
+	c.Bot.Client.SendChatAction(c.ChatId(), tg.ChatActionTyping)
+}
+
+// Reply sends a text message back to the chat that triggered this update
+func (c *Context) Reply(message string) error {
+	return c.ReplyWithKeyboard(message, allKeyboards)
+}
+
+// ReplyWithKeyboard sends a text message with a custom reply keyboard, eg.
+// the cancelKeyboard shown while a multi-step command is awaiting input
+func (c *Context) ReplyWithKeyboard(message string, keyboard [][]tg.KeyboardButton) error {
+	if sent := c.Bot.Client.SendMessage(c.ChatId(), &message, optionsWithKeyboard(keyboard)); !sent.Ok {
+		return fmt.Errorf("failed to send message: %s", *sent.Description)
+	}
+	return nil
+}
+
+// SendPhoto sends a single photo at the given filepath
+func (c *Context) SendPhoto(filepath string) error {
+	c.Bot.Client.SendChatAction(c.ChatId(), tg.ChatActionUploadPhoto)
+
+	if sent := c.Bot.Client.SendPhoto(c.ChatId(), &filepath, replyOptions()); !sent.Ok {
+		return fmt.Errorf("failed to send photo: %s", *sent.Description)
+	}
+	return nil
+}
+
+// SendVideo sends a video file at the given filepath
+func (c *Context) SendVideo(filepath string) error {
+	c.Bot.Client.SendChatAction(c.ChatId(), tg.ChatActionUploadVideo)
+
+	if sent := c.Bot.Client.SendVideo(c.ChatId(), &filepath, replyOptions()); !sent.Ok {
+		return fmt.Errorf("failed to send video: %s", *sent.Description)
+	}
+	return nil
+}
+
+// SendDocument sends an arbitrary file at the given filepath, eg. a zipped
+// set of timelapse frames
+func (c *Context) SendDocument(filepath string) error {
+	c.Bot.Client.SendChatAction(c.ChatId(), tg.ChatActionUploadDocument)
+
+	if sent := c.Bot.Client.SendDocument(c.ChatId(), &filepath, replyOptions()); !sent.Ok {
+		return fmt.Errorf("failed to send document: %s", *sent.Description)
+	}
+	return nil
+}
+
+// SendAlbum sends several photos at the given filepaths as a media group
+func (c *Context) SendAlbum(filepaths []string) error {
+	c.Bot.Client.SendChatAction(c.ChatId(), tg.ChatActionUploadPhoto)
+
+	media := make([]tg.InputMedia, 0, len(filepaths))
+	for _, fp := range filepaths {
+		media = append(media, tg.NewInputMediaPhoto(tg.NewInputFileFromFilepath(fp)))
+	}
+
+	if sent := c.Bot.Client.SendMediaGroup(c.ChatId(), media, nil); !sent.Ok {
+		return fmt.Errorf("failed to send album: %s", *sent.Description)
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"log"
+	"strings"
+
+	tg "github.com/meinside/telegram-bot-go"
+)
+
+// Handler processes a single update through a *Context
+type Handler func(ctx *Context) error
+
+// Middleware wraps a Handler with cross-cutting behavior (auth, logging, ...)
+type Middleware func(Handler) Handler
+
+// Reporter is notified of an error recovered from a panicking Handler
+type Reporter func(ctx *Context, err error)
+
+// InlineHandler answers an inline query (@botname ... from any chat)
+type InlineHandler func(b *Bot, query tg.InlineQuery) error
+
+type registeredHandler struct {
+	command string
+	handler Handler
+}
+
+// Bot dispatches incoming updates to registered command handlers through a
+// chain of middlewares, similar to telebot v3's Bot/Handle model.
+type Bot struct {
+	Client        *tg.Bot
+	handlers      []registeredHandler
+	stateHandlers map[Status]Handler
+	fallback      Handler
+	middlewares   []Middleware
+	inlineHandler InlineHandler
+}
+
+// NewBot creates a Bot wrapping a new telegram-bot-go client
+func NewBot(apiToken string) *Bot {
+	return &Bot{
+		Client: tg.NewClient(apiToken),
+	}
+}
+
+// Use appends a middleware to the chain. Middlewares registered first run
+// outermost, ie. they see the update before the ones registered after them.
+func (b *Bot) Use(middleware Middleware) {
+	b.middlewares = append(b.middlewares, middleware)
+}
+
+// Handle registers a handler for updates whose text starts with the given command
+func (b *Bot) Handle(command string, handler Handler) {
+	b.handlers = append(b.handlers, registeredHandler{command: command, handler: handler})
+}
+
+// Fallback registers the handler used when no command matches
+func (b *Bot) Fallback(handler Handler) {
+	b.fallback = handler
+}
+
+// HandleInline registers the handler used for incoming inline queries
+func (b *Bot) HandleInline(handler InlineHandler) {
+	b.inlineHandler = handler
+}
+
+// HandleState registers the handler used when a user's session is waiting
+// for free-form input as part of a multi-step command, eg. the seconds
+// argument of /video or the count/interval pair of /timelapse.
+func (b *Bot) HandleState(status Status, handler Handler) {
+	if b.stateHandlers == nil {
+		b.stateHandlers = map[Status]Handler{}
+	}
+	b.stateHandlers[status] = handler
+}
+
+// route picks the registered command handler matching ctx's text. If no
+// command matches and the session is mid multi-step command, the text is
+// delegated to the matching state handler instead. Otherwise, the fallback runs.
+func (b *Bot) route(ctx *Context) error {
+	txt := ctx.Text()
+	for _, rh := range b.handlers {
+		if strings.HasPrefix(txt, rh.command) {
+			return rh.handler(ctx)
+		}
+	}
+
+	if ctx.Session != nil {
+		ctx.Session.Lock()
+		status := ctx.Session.CurrentStatus
+		ctx.Session.Unlock()
+
+		if status != StatusWaiting {
+			if h, ok := b.stateHandlers[status]; ok {
+				return h(ctx)
+			}
+		}
+	}
+
+	if b.fallback != nil {
+		return b.fallback(ctx)
+	}
+	return nil
+}
+
+// Dispatch runs an incoming update through the middleware chain and the
+// matching handler. It returns false if the update carries neither a message
+// nor an inline query with a registered handler, or if handling it returned
+// an error; an update a middleware drops without an error returns true.
+func (b *Bot) Dispatch(update tg.Update) bool {
+	if update.InlineQuery != nil {
+		if b.inlineHandler == nil {
+			return false
+		}
+		if err := b.inlineHandler(b, *update.InlineQuery); err != nil {
+			log.Printf("*** Error while handling inline query: %s\n", err)
+			return false
+		}
+		return true
+	}
+
+	if update.Message == nil {
+		return false
+	}
+
+	h := b.route
+	for i := len(b.middlewares) - 1; i >= 0; i-- {
+		h = b.middlewares[i](h)
+	}
+
+	ctx := &Context{Bot: b, Update: update}
+	if err := h(ctx); err != nil {
+		log.Printf("*** Error while handling update: %s\n", err)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,72 @@
+// package helper provides utility functions for reading config, running
+// raspistill, and reporting process status
+package helper
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"time"
+
+	"github.com/meinside/telegram-bot-rpi-camera/conf"
+)
+
+const (
+	configFilename = "config.json"
+
+	raspiStillBin = "raspistill"
+)
+
+// GetConfig reads config file and returns parsed Config
+func GetConfig() (conf.Config, error) {
+	execFilepath, err := os.Executable()
+	if err != nil {
+		return conf.Config{}, err
+	}
+
+	var bytes []byte
+	bytes, err = ioutil.ReadFile(filepath.Join(filepath.Dir(execFilepath), configFilename))
+	if err != nil {
+		return conf.Config{}, err
+	}
+
+	var config conf.Config
+	if err = json.Unmarshal(bytes, &config); err != nil {
+		return conf.Config{}, err
+	}
+
+	return config, nil
+}
+
+// GetUptime returns uptime in human readable format
+func GetUptime(launched time.Time) string {
+	return time.Since(launched).String()
+}
+
+// GetMemoryUsage returns current memory usage in human readable format
+func GetMemoryUsage() string {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	return fmt.Sprintf("%.2f MB", float64(m.Sys)/1024/1024)
+}
+
+// CaptureRaspiStill captures a still image with `raspistill` and returns the path to it
+func CaptureRaspiStill(dir string, width, height int) (filepath string, err error) {
+	filepath = path(dir, "capture", "jpg")
+
+	cmd := exec.Command(raspiStillBin, "-w", fmt.Sprintf("%d", width), "-h", fmt.Sprintf("%d", height), "-o", filepath)
+	if err = cmd.Run(); err != nil {
+		return "", err
+	}
+
+	return filepath, nil
+}
+
+// path builds a timestamped filepath for a capture of the given kind and extension
+func path(dir, kind, ext string) string {
+	return filepath.Join(dir, fmt.Sprintf("%s_%d.%s", kind, time.Now().UnixNano(), ext))
+}
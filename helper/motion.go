@@ -0,0 +1,71 @@
+package helper
+
+import (
+	"image"
+	"image/jpeg"
+	"os"
+)
+
+const (
+	motionFrameWidth  = 64
+	motionFrameHeight = 48
+)
+
+// Frame is a downscaled grayscale snapshot cheap enough to diff on a Pi Zero
+type Frame struct {
+	Pixels []byte // motionFrameWidth * motionFrameHeight grayscale values
+}
+
+// LoadFrame decodes a jpeg file and downscales it (nearest-neighbour) into a
+// small grayscale Frame
+func LoadFrame(path string) (Frame, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return Frame{}, err
+	}
+	defer file.Close()
+
+	img, err := jpeg.Decode(file)
+	if err != nil {
+		return Frame{}, err
+	}
+
+	return downscaleGray(img), nil
+}
+
+// downscaleGray nearest-neighbour downscales img into a motionFrameWidth x
+// motionFrameHeight grayscale buffer
+func downscaleGray(img image.Image) Frame {
+	bounds := img.Bounds()
+	srcW, srcH := bounds.Dx(), bounds.Dy()
+
+	pixels := make([]byte, motionFrameWidth*motionFrameHeight)
+	for y := 0; y < motionFrameHeight; y++ {
+		sy := bounds.Min.Y + y*srcH/motionFrameHeight
+		for x := 0; x < motionFrameWidth; x++ {
+			sx := bounds.Min.X + x*srcW/motionFrameWidth
+			r, g, b, _ := img.At(sx, sy).RGBA()
+			pixels[y*motionFrameWidth+x] = byte((r*299 + g*587 + b*114) / 1000 >> 8)
+		}
+	}
+
+	return Frame{Pixels: pixels}
+}
+
+// Diff returns the mean absolute difference between two frames' pixels, in [0, 255]
+func (f Frame) Diff(other Frame) float64 {
+	if len(f.Pixels) == 0 || len(f.Pixels) != len(other.Pixels) {
+		return 0
+	}
+
+	total := 0
+	for i, p := range f.Pixels {
+		d := int(p) - int(other.Pixels[i])
+		if d < 0 {
+			d = -d
+		}
+		total += d
+	}
+
+	return float64(total) / float64(len(f.Pixels))
+}
@@ -0,0 +1,60 @@
+package helper
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestFrameDiffIdenticalFrames(t *testing.T) {
+	f := Frame{Pixels: []byte{10, 20, 30, 40}}
+
+	if d := f.Diff(f); d != 0 {
+		t.Errorf("Diff of a frame against itself = %v, want 0", d)
+	}
+}
+
+func TestFrameDiffMeanAbsoluteDifference(t *testing.T) {
+	a := Frame{Pixels: []byte{0, 0, 10, 255}}
+	b := Frame{Pixels: []byte{0, 10, 0, 0}}
+
+	// |0-0| + |0-10| + |10-0| + |255-0| = 275, / 4 = 68.75
+	want := 68.75
+	if d := a.Diff(b); d != want {
+		t.Errorf("Diff() = %v, want %v", d, want)
+	}
+}
+
+func TestFrameDiffMismatchedLengths(t *testing.T) {
+	a := Frame{Pixels: []byte{1, 2, 3}}
+	b := Frame{Pixels: []byte{1, 2}}
+
+	if d := a.Diff(b); d != 0 {
+		t.Errorf("Diff() with mismatched lengths = %v, want 0", d)
+	}
+}
+
+func TestDownscaleGrayDimensions(t *testing.T) {
+	src := image.NewGray(image.Rect(0, 0, 640, 480))
+	frame := downscaleGray(src)
+
+	if got, want := len(frame.Pixels), motionFrameWidth*motionFrameHeight; got != want {
+		t.Errorf("len(Pixels) = %d, want %d", got, want)
+	}
+}
+
+func TestDownscaleGrayPreservesUniformColor(t *testing.T) {
+	src := image.NewGray(image.Rect(0, 0, 640, 480))
+	for y := 0; y < 480; y++ {
+		for x := 0; x < 640; x++ {
+			src.Set(x, y, color.Gray{Y: 200})
+		}
+	}
+
+	frame := downscaleGray(src)
+	for i, p := range frame.Pixels {
+		if p != 200 {
+			t.Fatalf("Pixels[%d] = %d, want 200", i, p)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package helper
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// SnapshotMeta describes a single capture kept in a SnapshotCache
+type SnapshotMeta struct {
+	Id         string
+	Path       string
+	Width      int
+	Height     int
+	CapturedAt time.Time
+}
+
+// SnapshotCache keeps the most recent captures on disk under dir, so they
+// can be served back (eg. as inline query thumbnails) instead of being
+// deleted right after they're sent. Entries older than ttl, or beyond
+// maxEntries, are evicted and their files removed.
+type SnapshotCache struct {
+	dir        string
+	maxEntries int
+	ttl        time.Duration
+
+	mutex   sync.Mutex
+	entries []SnapshotMeta
+}
+
+// NewSnapshotCache creates a SnapshotCache rooted at dir
+func NewSnapshotCache(dir string, maxEntries int, ttl time.Duration) *SnapshotCache {
+	return &SnapshotCache{
+		dir:        dir,
+		maxEntries: maxEntries,
+		ttl:        ttl,
+	}
+}
+
+// Store moves a freshly-captured file into the cache directory and tracks it
+func (c *SnapshotCache) Store(srcPath string, width, height int) (SnapshotMeta, error) {
+	if err := os.MkdirAll(c.dir, 0755); err != nil {
+		return SnapshotMeta{}, err
+	}
+
+	name := fmt.Sprintf("%d%s", time.Now().UnixNano(), filepath.Ext(srcPath))
+	dstPath := filepath.Join(c.dir, name)
+	if err := os.Rename(srcPath, dstPath); err != nil {
+		return SnapshotMeta{}, err
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	meta := SnapshotMeta{
+		Id:         name,
+		Path:       dstPath,
+		Width:      width,
+		Height:     height,
+		CapturedAt: time.Now(),
+	}
+	c.entries = append(c.entries, meta)
+	c.evict()
+
+	return meta, nil
+}
+
+// Recent returns up to n of the most recently stored, non-expired snapshots
+func (c *SnapshotCache) Recent(n int) []SnapshotMeta {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.evict()
+
+	if n > len(c.entries) {
+		n = len(c.entries)
+	}
+	start := len(c.entries) - n
+
+	recent := make([]SnapshotMeta, n)
+	copy(recent, c.entries[start:])
+
+	return recent
+}
+
+// ById returns the cached snapshot with the given id, if it's still present
+func (c *SnapshotCache) ById(id string) (SnapshotMeta, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.evict()
+
+	for _, e := range c.entries {
+		if e.Id == id {
+			return e, true
+		}
+	}
+	return SnapshotMeta{}, false
+}
+
+// evict drops entries past ttl or beyond maxEntries, deleting their files.
+// Caller must hold c.mutex.
+func (c *SnapshotCache) evict() {
+	if c.ttl > 0 {
+		cutoff := time.Now().Add(-c.ttl)
+		kept := c.entries[:0]
+		for _, e := range c.entries {
+			if e.CapturedAt.Before(cutoff) {
+				os.Remove(e.Path)
+				continue
+			}
+			kept = append(kept, e)
+		}
+		c.entries = kept
+	}
+
+	for c.maxEntries > 0 && len(c.entries) > c.maxEntries {
+		os.Remove(c.entries[0].Path)
+		c.entries = c.entries[1:]
+	}
+}
@@ -0,0 +1,109 @@
+package helper
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestCapture(t *testing.T, dir, name string) string {
+	t.Helper()
+
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte("fake jpeg"), 0644); err != nil {
+		t.Fatalf("failed to write test capture: %s", err)
+	}
+	return path
+}
+
+func TestSnapshotCacheStoreAndRecent(t *testing.T) {
+	srcDir := t.TempDir()
+	cacheDir := filepath.Join(t.TempDir(), "cache")
+
+	cache := NewSnapshotCache(cacheDir, 0, 0)
+
+	for i := 0; i < 3; i++ {
+		src := newTestCapture(t, srcDir, "capture.jpg")
+		if _, err := cache.Store(src, 640, 480); err != nil {
+			t.Fatalf("Store() error = %s", err)
+		}
+	}
+
+	recent := cache.Recent(2)
+	if len(recent) != 2 {
+		t.Fatalf("Recent(2) returned %d entries, want 2", len(recent))
+	}
+	for _, meta := range recent {
+		if _, err := os.Stat(meta.Path); err != nil {
+			t.Errorf("cached file %s does not exist: %s", meta.Path, err)
+		}
+	}
+}
+
+func TestSnapshotCacheEvictsBeyondMaxEntries(t *testing.T) {
+	srcDir := t.TempDir()
+	cacheDir := filepath.Join(t.TempDir(), "cache")
+
+	cache := NewSnapshotCache(cacheDir, 2, 0)
+
+	var stored []string
+	for i := 0; i < 3; i++ {
+		src := newTestCapture(t, srcDir, "capture.jpg")
+		meta, err := cache.Store(src, 640, 480)
+		if err != nil {
+			t.Fatalf("Store() error = %s", err)
+		}
+		stored = append(stored, meta.Path)
+	}
+
+	if got := len(cache.Recent(10)); got != 2 {
+		t.Fatalf("Recent(10) returned %d entries, want 2", got)
+	}
+	if _, err := os.Stat(stored[0]); !os.IsNotExist(err) {
+		t.Errorf("oldest entry %s should have been evicted from disk", stored[0])
+	}
+}
+
+func TestSnapshotCacheEvictsExpiredEntries(t *testing.T) {
+	srcDir := t.TempDir()
+	cacheDir := filepath.Join(t.TempDir(), "cache")
+
+	cache := NewSnapshotCache(cacheDir, 0, time.Millisecond)
+
+	src := newTestCapture(t, srcDir, "capture.jpg")
+	meta, err := cache.Store(src, 640, 480)
+	if err != nil {
+		t.Fatalf("Store() error = %s", err)
+	}
+
+	time.Sleep(5 * time.Millisecond)
+
+	if got := len(cache.Recent(10)); got != 0 {
+		t.Fatalf("Recent(10) returned %d entries after ttl expired, want 0", got)
+	}
+	if _, err := os.Stat(meta.Path); !os.IsNotExist(err) {
+		t.Errorf("expired entry %s should have been removed from disk", meta.Path)
+	}
+}
+
+func TestSnapshotCacheById(t *testing.T) {
+	srcDir := t.TempDir()
+	cacheDir := filepath.Join(t.TempDir(), "cache")
+
+	cache := NewSnapshotCache(cacheDir, 0, 0)
+
+	src := newTestCapture(t, srcDir, "capture.jpg")
+	meta, err := cache.Store(src, 640, 480)
+	if err != nil {
+		t.Fatalf("Store() error = %s", err)
+	}
+
+	if got, ok := cache.ById(meta.Id); !ok || got.Path != meta.Path {
+		t.Errorf("ById(%q) = %+v, %v; want %+v, true", meta.Id, got, ok, meta)
+	}
+
+	if _, ok := cache.ById("does-not-exist"); ok {
+		t.Errorf("ById() for unknown id reported ok = true")
+	}
+}
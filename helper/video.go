@@ -0,0 +1,113 @@
+package helper
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+)
+
+const (
+	raspividBin = "raspivid"
+	ffmpegBin   = "ffmpeg"
+)
+
+// CaptureRaspiVid records a video clip with `raspivid`, muxes it into an mp4
+// with `ffmpeg`, and returns the path to the resulting mp4 file.
+func CaptureRaspiVid(dir string, seconds, width, height, bitrate int) (outputPath string, err error) {
+	h264Path := path(dir, "video", "h264")
+	defer os.Remove(h264Path)
+
+	cmd := exec.Command(raspividBin,
+		"-t", fmt.Sprintf("%d", seconds*1000),
+		"-w", fmt.Sprintf("%d", width),
+		"-h", fmt.Sprintf("%d", height),
+		"-b", fmt.Sprintf("%d", bitrate),
+		"-o", h264Path,
+	)
+	if err = cmd.Run(); err != nil {
+		return "", err
+	}
+
+	outputPath = path(dir, "video", "mp4")
+	cmd = exec.Command(ffmpegBin, "-y", "-r", "30", "-i", h264Path, "-c", "copy", outputPath)
+	if err = cmd.Run(); err != nil {
+		return "", err
+	}
+
+	return outputPath, nil
+}
+
+// CaptureTimelapse captures `count` still frames `intervalMs` milliseconds
+// apart with `raspistill --timelapse`, and returns a zip archive of them.
+func CaptureTimelapse(dir string, count, intervalMs, width, height int) (zipPath string, err error) {
+	base := fmt.Sprintf("timelapse_%d", time.Now().UnixNano())
+	pattern := filepath.Join(dir, base+"_%04d.jpg")
+
+	cmd := exec.Command(raspiStillBin,
+		"-w", fmt.Sprintf("%d", width),
+		"-h", fmt.Sprintf("%d", height),
+		"-t", fmt.Sprintf("%d", count*intervalMs),
+		"-tl", fmt.Sprintf("%d", intervalMs),
+		"-o", pattern,
+	)
+	if err = cmd.Run(); err != nil {
+		return "", err
+	}
+
+	frames := make([]string, 0, count)
+	for i := 1; i <= count; i++ {
+		frames = append(frames, filepath.Join(dir, fmt.Sprintf("%s_%04d.jpg", base, i)))
+	}
+
+	zipPath = filepath.Join(dir, base+".zip")
+	if err = zipFiles(zipPath, frames); err != nil {
+		return "", err
+	}
+
+	for _, f := range frames {
+		_ = os.Remove(f)
+	}
+
+	return zipPath, nil
+}
+
+// zipFiles archives the given files into a new zip file at zipPath
+func zipFiles(zipPath string, files []string) error {
+	out, err := os.Create(zipPath)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	w := zip.NewWriter(out)
+	defer w.Close()
+
+	for _, f := range files {
+		if err := addFileToZip(w, f); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// addFileToZip copies a single file's content into an open zip.Writer
+func addFileToZip(w *zip.Writer, filename string) error {
+	in, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	fw, err := w.Create(filepath.Base(filename))
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(fw, in)
+	return err
+}
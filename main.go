@@ -4,12 +4,15 @@ package main
 import (
 	"fmt"
 	"log"
+	"net/http"
 	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
-	bot "github.com/meinside/telegram-bot-go"
+	tg "github.com/meinside/telegram-bot-go"
 
 	"github.com/meinside/telegram-bot-rpi-camera/conf"
 	"github.com/meinside/telegram-bot-rpi-camera/helper"
@@ -19,6 +22,8 @@ type Status int16
 
 const (
 	StatusWaiting Status = iota
+	StatusWaitingVideoSeconds
+	StatusWaitingTimelapseParams
 )
 
 const (
@@ -26,16 +31,27 @@ const (
 
 	MinImageWidth  = 400
 	MinImageHeight = 300
+
+	MotionFrameWidth  = 320
+	MotionFrameHeight = 240
 )
 
+// Session carries one user's state. It has its own mutex so that concurrent
+// requests from different users never block each other; only concurrent
+// requests from the *same* user do.
 type Session struct {
+	sync.Mutex
+
 	UserId        string
+	ChatId        int64
 	CurrentStatus Status
+	MotionEnabled bool
 }
 
+// SessionPool holds one Session per available user. It's built once at
+// startup and never mutated afterwards, so the map itself needs no locking.
 type SessionPool struct {
-	Sessions map[string]Session
-	sync.Mutex
+	Sessions map[string]*Session
 }
 
 // variables
@@ -44,23 +60,35 @@ var monitorInterval int
 var isVerbose bool
 var availableIds []string
 var imageWidth, imageHeight int
+var videoMaxSeconds, videoBitrate, videoWidth, videoHeight int
+var cacheDir string
+var snapshotCache *helper.SnapshotCache
+var motionInterval int
+var motionSensitivity float64
+var maxCapturesPerMinute int
 var pool SessionPool
 var launched time.Time
+var webhookConfig conf.WebhookConfig
 
 // keyboards
-var allKeyboards = [][]bot.KeyboardButton{
-	bot.NewKeyboardButtons(conf.CommandCapture),
-	bot.NewKeyboardButtons(conf.CommandStatus, conf.CommandHelp),
+var allKeyboards = [][]tg.KeyboardButton{
+	tg.NewKeyboardButtons(conf.CommandCapture, conf.CommandVideo, conf.CommandTimelapse),
+	tg.NewKeyboardButtons(conf.CommandStatus, conf.CommandHelp),
 }
-var cancelKeyboard = [][]bot.KeyboardButton{
-	bot.NewKeyboardButtons(conf.CommandCancel),
+var cancelKeyboard = [][]tg.KeyboardButton{
+	tg.NewKeyboardButtons(conf.CommandCancel),
 }
 
 // initialization
 func init() {
 	launched = time.Now()
+}
 
-	// read variables from config file
+// loadConfig reads the config file and populates the package-level
+// variables derived from it. It's called from main() rather than init(),
+// so that loading it (and this file's unit tests) don't require every `go
+// test` invocation to run next to a real config.json.
+func loadConfig() {
 	if config, err := helper.GetConfig(); err == nil {
 		apiToken = config.ApiToken
 		availableIds = config.AvailableIds
@@ -77,11 +105,57 @@ func init() {
 		if imageHeight < MinImageHeight {
 			imageHeight = MinImageHeight
 		}
+		webhookConfig = config.Webhook
+
+		videoMaxSeconds = config.VideoMaxSeconds
+		if videoMaxSeconds <= 0 {
+			videoMaxSeconds = conf.DefaultVideoMaxSeconds
+		}
+		videoBitrate = config.VideoBitrate
+		if videoBitrate <= 0 {
+			videoBitrate = conf.DefaultVideoBitrate
+		}
+		videoWidth = config.VideoWidth
+		if videoWidth < MinImageWidth {
+			videoWidth = MinImageWidth
+		}
+		videoHeight = config.VideoHeight
+		if videoHeight < MinImageHeight {
+			videoHeight = MinImageHeight
+		}
+
+		cacheDir = config.CacheDir
+		if cacheDir == "" {
+			cacheDir = filepath.Join(TempDir, "cache")
+		}
+		cacheMaxEntries := config.CacheMaxEntries
+		if cacheMaxEntries <= 0 {
+			cacheMaxEntries = conf.DefaultCacheMaxEntries
+		}
+		cacheTTLSeconds := config.CacheTTLSeconds
+		if cacheTTLSeconds <= 0 {
+			cacheTTLSeconds = conf.DefaultCacheTTLSeconds
+		}
+		snapshotCache = helper.NewSnapshotCache(cacheDir, cacheMaxEntries, time.Duration(cacheTTLSeconds)*time.Second)
+
+		motionInterval = config.Motion.IntervalSeconds
+		if motionInterval <= 0 {
+			motionInterval = conf.DefaultMotionIntervalSeconds
+		}
+		motionSensitivity = config.Motion.Sensitivity
+		if motionSensitivity <= 0 {
+			motionSensitivity = conf.DefaultMotionSensitivity
+		}
+
+		maxCapturesPerMinute = config.MaxCapturesPerMinute
+		if maxCapturesPerMinute <= 0 {
+			maxCapturesPerMinute = conf.DefaultMaxCapturesPerMinute
+		}
 
 		// initialize variables
-		sessions := make(map[string]Session)
+		sessions := make(map[string]*Session)
 		for _, v := range availableIds {
-			sessions[v] = Session{
+			sessions[v] = &Session{
 				UserId:        v,
 				CurrentStatus: StatusWaiting,
 			}
@@ -94,6 +168,51 @@ func init() {
 	}
 }
 
+// check if webhook mode is fully configured
+func webhookEnabled() bool {
+	return webhookConfig.URL != "" &&
+		webhookConfig.CertFile != "" &&
+		webhookConfig.KeyFile != "" &&
+		webhookConfig.ListenAddr != "" &&
+		webhookConfig.SecretToken != ""
+}
+
+// setStatus updates a user's session status in the pool
+func setStatus(userId string, status Status) {
+	session, exists := pool.Sessions[userId]
+	if !exists {
+		return
+	}
+
+	session.Lock()
+	session.CurrentStatus = status
+	session.Unlock()
+}
+
+// setMotionEnabled toggles a user's motion alert subscription in the pool
+func setMotionEnabled(userId string, enabled bool) {
+	session, exists := pool.Sessions[userId]
+	if !exists {
+		return
+	}
+
+	session.Lock()
+	session.MotionEnabled = enabled
+	session.Unlock()
+}
+
+// motionEnabledFor reports whether a user is currently subscribed to motion alerts
+func motionEnabledFor(userId string) bool {
+	session, exists := pool.Sessions[userId]
+	if !exists {
+		return false
+	}
+
+	session.Lock()
+	defer session.Unlock()
+	return session.MotionEnabled
+}
+
 // check if given Telegram id is available
 func isAvailableId(id string) bool {
 	for _, v := range availableIds {
@@ -112,9 +231,13 @@ Following commands are supported:
 *For Raspberry Pi Camera Module*
 
 /capture : capture an still image with *raspistill*
+/video <seconds> : record a video with *raspivid*
+/timelapse <count> <interval> : capture a timelapse with *raspistill*
 
 *Others*
 
+/motion on|off|status : toggle motion-triggered alerts
+/cancel : cancel the command in progress
 /status : show this bot's status
 /help : show this help message
 `
@@ -122,127 +245,423 @@ Following commands are supported:
 
 // for showing current status of this bot
 func getStatus() string {
-	return fmt.Sprintf("Uptime: %s\nMemory Usage: %s", helper.GetUptime(launched), helper.GetMemoryUsage())
+	return fmt.Sprintf("Uptime: %s\nMemory Usage: %s\nCapture queue depth: %d\nLast capture duration: %s",
+		helper.GetUptime(launched), helper.GetMemoryUsage(), captureQueueDepth(), lastCaptureDuration())
 }
 
-// process incoming update from Telegram
-func processUpdate(b *bot.Bot, update bot.Update) bool {
-	// check username
-	var userId string
-	if update.Message.From.Username == nil {
-		log.Printf("*** Not allowed (no user name): %s\n", *update.Message.From.FirstName)
-		return false
-	}
-	userId = *update.Message.From.Username
-	if !isAvailableId(userId) {
-		log.Printf("*** Id not allowed: %s\n", userId)
-		return false
+// default reply keyboard markup, attached to every outgoing message
+func replyOptions() map[string]interface{} {
+	return optionsWithKeyboard(allKeyboards)
+}
+
+// message options with a custom reply keyboard attached
+func optionsWithKeyboard(keyboard [][]tg.KeyboardButton) map[string]interface{} {
+	return map[string]interface{}{
+		"reply_markup": tg.ReplyKeyboardMarkup{
+			Keyboard:       keyboard,
+			ResizeKeyboard: true,
+		},
+		"parse_mode": tg.ParseModeMarkdown,
 	}
+}
 
-	// process result
-	result := false
+// handler for /start
+func handleStart(ctx *Context) error {
+	return ctx.Reply(conf.MessageDefault)
+}
 
-	pool.Lock()
-	if session, exists := pool.Sessions[userId]; exists {
-		// text from message
-		var txt string
-		if update.Message.HasText() {
-			txt = *update.Message.Text
-		} else {
-			txt = ""
-		}
-
-		var message string
-		var options map[string]interface{} = map[string]interface{}{
-			"reply_markup": bot.ReplyKeyboardMarkup{
-				Keyboard:       allKeyboards,
-				ResizeKeyboard: true,
-			},
-			"parse_mode": bot.ParseModeMarkdown,
-		}
-
-		switch session.CurrentStatus {
-		case StatusWaiting:
-			switch {
-			// start
-			case strings.HasPrefix(txt, conf.CommandStart):
-				message = conf.MessageDefault
-			// capture
-			case strings.HasPrefix(txt, conf.CommandCapture):
-				message = ""
-			// status
-			case strings.HasPrefix(txt, conf.CommandStatus):
-				message = getStatus()
-			// help
-			case strings.HasPrefix(txt, conf.CommandHelp):
-				message = getHelp()
-			// fallback
-			default:
-				message = fmt.Sprintf("*%s*: %s", txt, conf.MessageUnknownCommand)
+// handler for /help
+func handleHelp(ctx *Context) error {
+	return ctx.Reply(getHelp())
+}
+
+// handler for /status
+func handleStatus(ctx *Context) error {
+	return ctx.Reply(getStatus())
+}
+
+// handler for /capture
+func handleCapture(ctx *Context) error {
+	ctx.Typing()
+
+	captured, err := enqueueCapture(func() (string, error) {
+		return helper.CaptureRaspiStill(TempDir, imageWidth, imageHeight)
+	})
+	if err != nil {
+		return fmt.Errorf("image capture failed: %s", err)
+	}
+
+	// keep the capture in the snapshot cache (instead of deleting it right
+	// away) so it can also be served through inline queries
+	cached, err := snapshotCache.Store(captured, imageWidth, imageHeight)
+	if err != nil {
+		log.Printf("*** Failed to cache capture: %s\n", err)
+		defer func() {
+			if err := os.Remove(captured); err != nil {
+				log.Printf("*** Failed to delete temp file: %s\n", err)
 			}
+		}()
+		return ctx.SendPhoto(captured)
+	}
+
+	return ctx.SendPhoto(cached.Path)
+}
+
+// handler for /video, optionally followed by a number of seconds
+func handleVideo(ctx *Context) error {
+	arg := strings.TrimSpace(strings.TrimPrefix(ctx.Text(), conf.CommandVideo))
+	if arg == "" {
+		setStatus(ctx.UserId, StatusWaitingVideoSeconds)
+		return ctx.ReplyWithKeyboard(fmt.Sprintf("How many seconds to record? (max %d)", videoMaxSeconds), cancelKeyboard)
+	}
+	return captureVideo(ctx, arg)
+}
+
+// state handler for the seconds argument of a /video started without one
+func handleVideoSeconds(ctx *Context) error {
+	defer setStatus(ctx.UserId, StatusWaiting)
+	return captureVideo(ctx, ctx.Text())
+}
+
+func captureVideo(ctx *Context, arg string) error {
+	seconds, err := strconv.Atoi(strings.TrimSpace(arg))
+	if err != nil || seconds <= 0 {
+		return ctx.Reply("Please send a positive number of seconds.")
+	}
+	if seconds > videoMaxSeconds {
+		seconds = videoMaxSeconds
+	}
+
+	if !allowCapture(ctx.UserId) {
+		return ctx.Reply("You're capturing too often - please wait a bit and try again.")
+	}
+
+	ctx.Typing()
+
+	filepath, err := enqueueCapture(func() (string, error) {
+		return helper.CaptureRaspiVid(TempDir, seconds, videoWidth, videoHeight, videoBitrate)
+	})
+	if err != nil {
+		return fmt.Errorf("video capture failed: %s", err)
+	}
+	defer func() {
+		if err := os.Remove(filepath); err != nil {
+			log.Printf("*** Failed to delete temp file: %s\n", err)
+		}
+	}()
+
+	return ctx.SendVideo(filepath)
+}
+
+// handler for /timelapse, optionally followed by "<count> <interval>"
+func handleTimelapse(ctx *Context) error {
+	arg := strings.TrimSpace(strings.TrimPrefix(ctx.Text(), conf.CommandTimelapse))
+	if arg == "" {
+		setStatus(ctx.UserId, StatusWaitingTimelapseParams)
+		return ctx.ReplyWithKeyboard("Send a frame count and an interval in seconds, eg. `10 5`", cancelKeyboard)
+	}
+	return captureTimelapse(ctx, arg)
+}
+
+// state handler for the "<count> <interval>" argument of a /timelapse started without one
+func handleTimelapseParams(ctx *Context) error {
+	defer setStatus(ctx.UserId, StatusWaiting)
+	return captureTimelapse(ctx, ctx.Text())
+}
+
+func captureTimelapse(ctx *Context, arg string) error {
+	fields := strings.Fields(arg)
+	if len(fields) != 2 {
+		return ctx.Reply("Please send a frame count and an interval in seconds, eg. `10 5`")
+	}
+
+	count, countErr := strconv.Atoi(fields[0])
+	interval, intervalErr := strconv.Atoi(fields[1])
+	if countErr != nil || intervalErr != nil || count <= 0 || interval <= 0 {
+		return ctx.Reply("Please send a positive frame count and interval.")
+	}
+
+	if !allowCapture(ctx.UserId) {
+		return ctx.Reply("You're capturing too often - please wait a bit and try again.")
+	}
+
+	ctx.Typing()
+
+	filepath, err := enqueueCapture(func() (string, error) {
+		return helper.CaptureTimelapse(TempDir, count, interval*1000, videoWidth, videoHeight)
+	})
+	if err != nil {
+		return fmt.Errorf("timelapse capture failed: %s", err)
+	}
+	defer func() {
+		if err := os.Remove(filepath); err != nil {
+			log.Printf("*** Failed to delete temp file: %s\n", err)
 		}
+	}()
+
+	return ctx.SendDocument(filepath)
+}
 
-		if len(message) > 0 {
-			// send message
-			if sent := b.SendMessage(update.Message.Chat.Id, &message, options); sent.Ok {
-				result = true
-			} else {
-				log.Printf("*** Failed to send message: %s\n", *sent.Description)
+// handler for /cancel, aborting any multi-step command in progress
+func handleCancel(ctx *Context) error {
+	setStatus(ctx.UserId, StatusWaiting)
+	return ctx.Reply("Canceled.")
+}
+
+// handler for /motion on|off|status
+func handleMotion(ctx *Context) error {
+	arg := strings.TrimSpace(strings.TrimPrefix(ctx.Text(), conf.CommandMotion))
+	switch arg {
+	case "on":
+		setMotionEnabled(ctx.UserId, true)
+		return ctx.Reply("Motion alerts are now *on*.")
+	case "off":
+		setMotionEnabled(ctx.UserId, false)
+		return ctx.Reply("Motion alerts are now *off*.")
+	case "", "status":
+		if motionEnabledFor(ctx.UserId) {
+			return ctx.Reply("Motion alerts are *on*.")
+		}
+		return ctx.Reply("Motion alerts are *off*.")
+	default:
+		return ctx.Reply("Usage: `/motion on|off|status`")
+	}
+}
+
+// watchMotion periodically grabs a low-res frame and compares it to the
+// previous one; when the difference crosses motionSensitivity, it notifies
+// every subscribed user with a full-resolution capture.
+func watchMotion(b *Bot) {
+	var previous *helper.Frame
+
+	ticker := time.NewTicker(time.Duration(motionInterval) * time.Second)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		framePath, err := enqueueCapture(func() (string, error) {
+			return helper.CaptureRaspiStill(TempDir, MotionFrameWidth, MotionFrameHeight)
+		})
+		if err != nil {
+			log.Printf("*** Motion capture failed: %s\n", err)
+			continue
+		}
+
+		frame, err := helper.LoadFrame(framePath)
+		if err := os.Remove(framePath); err != nil {
+			log.Printf("*** Failed to delete temp file: %s\n", err)
+		}
+		if err != nil {
+			log.Printf("*** Failed to decode motion frame: %s\n", err)
+			continue
+		}
+
+		if previous != nil && previous.Diff(frame) >= motionSensitivity {
+			notifyMotion(b)
+		}
+		previous = &frame
+	}
+}
+
+// notifyMotion captures a full-resolution photo and sends it to every user
+// currently subscribed to motion alerts
+func notifyMotion(b *Bot) {
+	captured, err := enqueueCapture(func() (string, error) {
+		return helper.CaptureRaspiStill(TempDir, imageWidth, imageHeight)
+	})
+	if err != nil {
+		log.Printf("*** Motion-triggered capture failed: %s\n", err)
+		return
+	}
+
+	path := captured
+	if cached, err := snapshotCache.Store(captured, imageWidth, imageHeight); err == nil {
+		path = cached.Path
+	} else {
+		log.Printf("*** Failed to cache motion capture: %s\n", err)
+		defer func() {
+			if err := os.Remove(captured); err != nil {
+				log.Printf("*** Failed to delete temp file: %s\n", err)
 			}
+		}()
+	}
+
+	options := replyOptions()
+	for _, userId := range availableIds {
+		session, exists := pool.Sessions[userId]
+		if !exists {
+			continue
+		}
+
+		session.Lock()
+		chatId, enabled := session.ChatId, session.MotionEnabled
+		session.Unlock()
+
+		if !enabled || chatId == 0 {
+			continue
+		}
+
+		if sent := b.Client.SendPhoto(chatId, &path, options); !sent.Ok {
+			log.Printf("*** Failed to send motion alert to %s: %s\n", userId, *sent.Description)
+		}
+	}
+}
+
+// fallback handler for unrecognized text
+func handleUnknown(ctx *Context) error {
+	return ctx.Reply(fmt.Sprintf("*%s*: %s", ctx.Text(), conf.MessageUnknownCommand))
+}
+
+// handleInlineQuery answers @botname inline queries with thumbnails of the
+// most recent captures, served from the cache directory over the webhook's
+// TLS listener. Requires webhook mode, since that's the only HTTPS endpoint
+// Telegram can fetch thumbnails from.
+func handleInlineQuery(b *Bot, query tg.InlineQuery) error {
+	if query.From.Username == nil || !isAvailableId(*query.From.Username) {
+		log.Printf("*** Inline query not allowed: %+v\n", query.From)
+		return nil
+	}
+
+	if !webhookEnabled() {
+		return nil
+	}
+
+	snapshots := snapshotCache.Recent(conf.InlineQueryResultCount)
+
+	results := make([]interface{}, 0, len(snapshots))
+	for i := len(snapshots) - 1; i >= 0; i-- {
+		s := snapshots[i]
+		url := fmt.Sprintf("%s/snapshots/%s", webhookConfig.URL, filepath.Base(s.Path))
+
+		result := tg.NewInlineQueryResultPhoto(s.Id, url, url)
+		result.PhotoWidth = s.Width
+		result.PhotoHeight = s.Height
+		results = append(results, result)
+	}
+
+	if sent := b.Client.AnswerInlineQuery(query.Id, results, nil); !sent.Ok {
+		return fmt.Errorf("failed to answer inline query: %s", *sent.Description)
+	}
+	return nil
+}
+
+// serveSnapshot serves a single cached capture by id, looked up through
+// snapshotCache instead of a bare http.FileServer. This keeps the cache
+// directory from being listed and makes sure only captures still tracked
+// by snapshotCache are reachable, whether or not an inline query has
+// returned them.
+func serveSnapshot(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/snapshots/")
+	if id == "" || strings.Contains(id, "/") {
+		http.NotFound(w, r)
+		return
+	}
+
+	meta, ok := snapshotCache.ById(id)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	http.ServeFile(w, r, meta.Path)
+}
+
+// report a recovered error back to the user who triggered it
+func reportError(ctx *Context, err error) {
+	log.Printf("*** Recovered error: %s\n", err)
+	_ = ctx.Reply(fmt.Sprintf("*Error*: %s", err))
+}
+
+// run the bot in webhook mode: register the webhook URL with Telegram and
+// serve incoming updates over a TLS listener
+func runWebhook(b *Bot) {
+	if hooked := b.Client.SetWebhookUrlWithSecretToken(webhookConfig.URL, webhookConfig.CertFile, webhookConfig.SecretToken, false); !hooked.Ok {
+		panic("Failed to set webhook url")
+	}
+
+	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		// reject anything that doesn't carry the secret token we registered
+		// with Telegram, so a forged Update JSON can't be posted by anyone
+		// who merely discovers this host:port (eg. via CT logs)
+		if r.Header.Get("X-Telegram-Bot-Api-Secret-Token") != webhookConfig.SecretToken {
+			log.Printf("*** Rejected webhook request with missing/invalid secret token from %s\n", r.RemoteAddr)
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		update, err := b.Client.GetUpdateFromRequest(r)
+		if err != nil {
+			log.Printf("*** Error while receiving update (%s)\n", err.Error())
 		} else {
-			// 'typing...'
-			b.SendChatAction(update.Message.Chat.Id, bot.ChatActionTyping)
-
-			// send photo
-			if filepath, err := helper.CaptureRaspiStill(TempDir, imageWidth, imageHeight); err == nil {
-				// 'uploading photo...'
-				b.SendChatAction(update.Message.Chat.Id, bot.ChatActionUploadPhoto)
-
-				// send photo
-				if sent := b.SendPhoto(update.Message.Chat.Id, &filepath, options); sent.Ok {
-					if err := os.Remove(filepath); err != nil {
-						log.Printf("*** Failed to delete temp file: %s\n", err)
-					}
-					result = true
-				} else {
-					log.Printf("*** Failed to send photo: %s\n", *sent.Description)
-				}
-			} else {
-				log.Printf("*** Image capture failed: %s\n", err)
-			}
+			b.Dispatch(update)
 		}
-	} else {
-		log.Printf("*** Session does not exist for id: %s\n", userId)
+		w.WriteHeader(http.StatusOK)
+	})
+
+	// serve cached captures so Telegram can fetch inline query thumbnails
+	http.HandleFunc("/snapshots/", serveSnapshot)
+
+	log.Printf("Listening for webhook updates on %s\n", webhookConfig.ListenAddr)
+	if err := http.ListenAndServeTLS(webhookConfig.ListenAddr, webhookConfig.CertFile, webhookConfig.KeyFile, nil); err != nil {
+		panic(fmt.Sprintf("Failed to start webhook listener: %s", err))
 	}
-	pool.Unlock()
+}
 
-	return result
+// run the bot in long-polling mode
+func runPolling(b *Bot) {
+	// delete webhook (getting updates will not work when wehbook is set up)
+	if unhooked := b.Client.DeleteWebhook(); unhooked.Ok {
+		// wait for new updates
+		b.Client.StartMonitoringUpdates(0, monitorInterval, func(c *tg.Bot, update tg.Update, err error) {
+			if err != nil {
+				log.Printf("*** Error while receiving update (%s)\n", err.Error())
+				return
+			}
+			b.Dispatch(update)
+		})
+	} else {
+		panic("Failed to delete webhook")
+	}
 }
 
 func main() {
-	client := bot.NewClient(apiToken)
-	client.Verbose = isVerbose
+	loadConfig()
+
+	b := NewBot(apiToken)
+	b.Client.Verbose = isVerbose
 
 	// get info about this bot
-	if me := client.GetMe(); me.Ok {
+	if me := b.Client.GetMe(); me.Ok {
 		log.Printf("Launching bot: @%s (%s)\n", *me.Result.Username, *me.Result.FirstName)
 
-		// delete webhook (getting updates will not work when wehbook is set up)
-		if unhooked := client.DeleteWebhook(); unhooked.Ok {
-			// wait for new updates
-			client.StartMonitoringUpdates(0, monitorInterval, func(b *bot.Bot, update bot.Update, err error) {
-				if err == nil {
-					if update.Message != nil {
-						processUpdate(b, update)
-					}
-				} else {
-					log.Printf("*** Error while receiving update (%s)\n", err.Error())
-				}
-			})
+		// middlewares run outermost-first; Auth must run before RateLimit
+		// since the rate limiter keys off ctx.UserId
+		b.Use(RecoverMiddleware(reportError))
+		b.Use(LoggingMiddleware)
+		b.Use(AuthMiddleware)
+		b.Use(RateLimitMiddleware)
+
+		b.Handle(conf.CommandStart, handleStart)
+		b.Handle(conf.CommandCapture, handleCapture)
+		b.Handle(conf.CommandVideo, handleVideo)
+		b.Handle(conf.CommandTimelapse, handleTimelapse)
+		b.Handle(conf.CommandStatus, handleStatus)
+		b.Handle(conf.CommandHelp, handleHelp)
+		b.Handle(conf.CommandCancel, handleCancel)
+		b.Handle(conf.CommandMotion, handleMotion)
+		b.HandleState(StatusWaitingVideoSeconds, handleVideoSeconds)
+		b.HandleState(StatusWaitingTimelapseParams, handleTimelapseParams)
+		b.Fallback(handleUnknown)
+		b.HandleInline(handleInlineQuery)
+
+		startCaptureWorker()
+		go watchMotion(b)
+
+		if webhookEnabled() {
+			runWebhook(b)
 		} else {
-			panic("Failed to delete webhook")
+			runPolling(b)
 		}
 	} else {
 		panic("Failed to get info of the bot")
 	}
-}
\ No newline at end of file
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/meinside/telegram-bot-rpi-camera/conf"
+)
+
+// AuthMiddleware rejects updates from users missing a username or not listed
+// in availableIds, and attaches the sender's session to the Context.
+// This replaces the old ad-hoc isAvailableId check in processUpdate.
+func AuthMiddleware(next Handler) Handler {
+	return func(ctx *Context) error {
+		if ctx.Update.Message.From.Username == nil {
+			log.Printf("*** Not allowed (no user name): %s\n", *ctx.Update.Message.From.FirstName)
+			return nil
+		}
+
+		userId := *ctx.Update.Message.From.Username
+		if !isAvailableId(userId) {
+			log.Printf("*** Id not allowed: %s\n", userId)
+			return nil
+		}
+
+		session, exists := pool.Sessions[userId]
+		if !exists {
+			log.Printf("*** Session does not exist for id: %s\n", userId)
+			return nil
+		}
+
+		// each session carries its own mutex, so unrelated users never block
+		// each other here, only concurrent updates from the same user do
+		session.Lock()
+		session.ChatId = ctx.Update.Message.Chat.Id
+		session.Unlock()
+
+		ctx.UserId = userId
+		ctx.Session = session
+
+		return next(ctx)
+	}
+}
+
+// LoggingMiddleware logs every dispatched update and how long it took to handle
+func LoggingMiddleware(next Handler) Handler {
+	return func(ctx *Context) error {
+		started := time.Now()
+		err := next(ctx)
+		log.Printf("*** [%s] %s (%s)\n", ctx.UserId, ctx.Text(), time.Since(started))
+		return err
+	}
+}
+
+// tokenBucket is a simple per-user token bucket refilled at a constant rate
+type tokenBucket struct {
+	mutex      sync.Mutex
+	tokens     float64
+	capacity   float64
+	refillRate float64 // tokens per second
+	updatedAt  time.Time
+}
+
+func newTokenBucket(capacity, refillRate float64) *tokenBucket {
+	return &tokenBucket{
+		tokens:     capacity,
+		capacity:   capacity,
+		refillRate: refillRate,
+		updatedAt:  time.Now(),
+	}
+}
+
+// Allow reports whether a token is available, consuming one if so
+func (t *tokenBucket) Allow() bool {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	now := time.Now()
+	t.tokens += now.Sub(t.updatedAt).Seconds() * t.refillRate
+	if t.tokens > t.capacity {
+		t.tokens = t.capacity
+	}
+	t.updatedAt = now
+
+	if t.tokens < 1 {
+		return false
+	}
+	t.tokens--
+	return true
+}
+
+var captureLimiters sync.Map // userId -> *tokenBucket
+
+// RateLimitMiddleware rejects /capture requests once a user exceeds
+// maxCapturesPerMinute (max_captures_per_minute in config.json), via a
+// per-user token bucket.
+//
+// /video and /timelapse aren't gated here: both can also be triggered by a
+// bare follow-up message (eg. "15") routed through a state handler rather
+// than matching this text prefix, so they check allowCapture themselves,
+// right before the actual raspivid/raspistill call.
+func RateLimitMiddleware(next Handler) Handler {
+	return func(ctx *Context) error {
+		if !strings.HasPrefix(ctx.Text(), conf.CommandCapture) {
+			return next(ctx)
+		}
+
+		if !allowCapture(ctx.UserId) {
+			return ctx.Reply("You're capturing too often - please wait a bit and try again.")
+		}
+
+		return next(ctx)
+	}
+}
+
+// allowCapture reports whether userId may trigger another capture right now,
+// consuming a token from their bucket if so.
+func allowCapture(userId string) bool {
+	limiter, _ := captureLimiters.LoadOrStore(userId, newTokenBucket(float64(maxCapturesPerMinute), float64(maxCapturesPerMinute)/60))
+	return limiter.(*tokenBucket).Allow()
+}
+
+// RecoverMiddleware turns a panicking handler into a user-visible error,
+// reported through the given Reporter instead of crashing the bot.
+func RecoverMiddleware(reporter Reporter) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx *Context) (err error) {
+			defer func() {
+				if r := recover(); r != nil {
+					err = fmt.Errorf("recovered from panic: %v", r)
+					if reporter != nil {
+						reporter(ctx, err)
+					}
+				}
+			}()
+			return next(ctx)
+		}
+	}
+}